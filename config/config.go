@@ -0,0 +1,66 @@
+// Package config parses the command-line flags and positional arguments
+// the server is started with.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+type Config struct {
+	Debug bool
+	Port  int
+
+	TLSCertFile string
+	TLSKeyFile  string
+	GRPCPort    int
+
+	HTTPOrigin string
+
+	UnitConversionTableCSVFilename string
+	UnitConversionTableINIFilename string
+	UnitAliasTableFilename         string
+	ProductAliasMapFilename        string
+	AllergenTableFilename          string
+
+	// RecipeSourceFilename is the CSV file listing each recipe's source.
+	RecipeSourceFilename string
+	// RecipeIngredientFilenames lists the CSV files to import recipe
+	// ingredients from.
+	RecipeIngredientFilenames []string
+}
+
+// Parse parses os.Args[1:] into a Config, exiting the process with a usage
+// message if the required positional arguments are missing.
+func Parse() *Config {
+	cfg := &Config{}
+
+	flag.BoolVar(&cfg.Debug, "debug", false, "enable debug mode")
+	flag.IntVar(&cfg.Port, "port", 8080, "port to use for the HTTP server")
+	flag.IntVar(&cfg.GRPCPort, "grpcPort", 0, "port to use for the gRPC server (0 disables it)")
+	flag.StringVar(&cfg.TLSCertFile, "tlsCertFile", "", "TLS certificate file to use for HTTPS/gRPC")
+	flag.StringVar(&cfg.TLSKeyFile, "tlsKeyFile", "", "TLS key file to use for HTTPS/gRPC")
+	flag.StringVar(&cfg.HTTPOrigin, "httpOrigin", "", "HTTP origin to use with the Access-Control-Allow-Origin response header")
+	flag.StringVar(&cfg.UnitConversionTableCSVFilename, "conversionTableCSV", "", "load a conversion table from a CSV file with the given name")
+	flag.StringVar(&cfg.UnitConversionTableINIFilename, "conversionTableINI", "", "load a conversion table from an INI file with the given name")
+	flag.StringVar(&cfg.UnitAliasTableFilename, "unitAliasTable", "", "load an alias table from an INI file with the given name")
+	flag.StringVar(&cfg.ProductAliasMapFilename, "productAliasMap", "", "load a product alias map from an INI file with the given name")
+	flag.StringVar(&cfg.AllergenTableFilename, "allergenTable", "", "load an allergen table from an INI file with the given name")
+
+	flag.Parse()
+
+	if cfg.Debug {
+		cfg.HTTPOrigin = "*"
+	}
+
+	args := flag.Args()
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "not enough arguments")
+		os.Exit(1)
+	}
+	cfg.RecipeSourceFilename = args[0]
+	cfg.RecipeIngredientFilenames = args[1:]
+
+	return cfg
+}
@@ -0,0 +1,124 @@
+// Package grpc implements the FoodService gRPC transport defined in
+// proto/foodtp.proto, as an alternative to the JSON HTTP API in httpapi for
+// clients that want a strongly-typed schema. It shares the same in-memory
+// RecipeTable and UnitConversionContext the HTTP handlers use.
+//
+// The pb package it imports is generated from proto/foodtp.proto and is not
+// checked in; regenerate it with:
+//
+//	protoc --go_out=. --go-grpc_out=. proto/foodtp.proto
+package grpc
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/rgeorgiev583/foodtp-server/proto"
+	"github.com/rgeorgiev583/foodtp-server/recipes"
+	"github.com/rgeorgiev583/foodtp-server/units"
+)
+
+// Server implements pb.FoodServiceServer.
+type Server struct {
+	pb.UnimplementedFoodServiceServer
+
+	Products          []string
+	ProductUnitsMap   map[string]units.StringSet
+	Recipes           recipes.RecipeTable
+	RecipeSources     recipes.RecipeSourceMap
+	UnitConversion    *units.UnitConversionContext
+	UnitAlias         *units.UnitAliasContext
+	ProductAliasMap   units.BaseAliasMap
+	ProductDensityMap units.ProductDensityMap
+	AllergenTable     *units.AllergenTable
+}
+
+func (s *Server) ListProducts(ctx context.Context, request *pb.ListProductsRequest) (*pb.ListProductsResponse, error) {
+	return &pb.ListProductsResponse{Products: s.Products}, nil
+}
+
+func (s *Server) ListUnitsForProduct(ctx context.Context, request *pb.ProductUnitsRequest) (*pb.ProductUnitsResponse, error) {
+	productUnits, ok := s.ProductUnitsMap[request.Product]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown product %q", request.Product)
+	}
+	return &pb.ProductUnitsResponse{Units: productUnits.Sorted()}, nil
+}
+
+func (s *Server) productMapFromProto(protoProducts []*pb.Product) (recipes.ProductMap, error) {
+	availableProducts := make(recipes.ProductMap, len(protoProducts))
+	for _, protoProduct := range protoProducts {
+		if protoProduct.Measurement == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "product %q has no measurement", protoProduct.Name)
+		}
+
+		product := &recipes.Product{
+			Name: protoProduct.Name,
+			Measurement: &units.Measurement{
+				Quantity: protoProduct.Measurement.Quantity,
+				Unit:     protoProduct.Measurement.Unit,
+			},
+		}
+		product.ConvertUnit(s.UnitConversion, s.UnitAlias, s.ProductAliasMap)
+		availableProducts[product.Name] = product
+	}
+	return availableProducts, nil
+}
+
+func excludedAllergenSet(excludedAllergens []string) units.StringSet {
+	set := make(units.StringSet, len(excludedAllergens))
+	for _, allergen := range excludedAllergens {
+		set[allergen] = struct{}{}
+	}
+	return set
+}
+
+func (s *Server) recipeSuggestionSet(recipeNames []string) *pb.RecipeSuggestionSet {
+	recipeSuggestions := make([]*pb.RecipeSuggestion, 0, len(recipeNames))
+	for _, recipeName := range recipeNames {
+		recipeSuggestions = append(recipeSuggestions, &pb.RecipeSuggestion{
+			Name:      recipeName,
+			Source:    s.RecipeSources[recipeName],
+			Allergens: s.Recipes.Allergens(recipeName),
+		})
+	}
+	return &pb.RecipeSuggestionSet{Recipes: recipeSuggestions}
+}
+
+func (s *Server) SuggestRecipes(ctx context.Context, request *pb.RecipeSuggestionsRequest) (*pb.RecipeSuggestionsResponse, error) {
+	availableProducts, err := s.productMapFromProto(request.AvailableProducts)
+	if err != nil {
+		return nil, err
+	}
+	excludedAllergens := excludedAllergenSet(request.ExcludedAllergens)
+
+	matchingRecipeNameSets := s.Recipes.GetMatchingRecipeNameSets(ctx, availableProducts, s.ProductDensityMap, recipes.UniformRecipeScale(int(request.NumberOfServings)), int(request.MaxSuggestions), excludedAllergens)
+
+	recipeSets := make([]*pb.RecipeSuggestionSet, 0, len(matchingRecipeNameSets))
+	for _, matchingRecipeNameSet := range matchingRecipeNameSets {
+		recipeSets = append(recipeSets, s.recipeSuggestionSet(matchingRecipeNameSet))
+	}
+
+	return &pb.RecipeSuggestionsResponse{RecipeSets: recipeSets}, nil
+}
+
+func (s *Server) SuggestRecipesStream(request *pb.RecipeSuggestionsRequest, stream pb.FoodService_SuggestRecipesStreamServer) error {
+	availableProducts, err := s.productMapFromProto(request.AvailableProducts)
+	if err != nil {
+		return err
+	}
+	excludedAllergens := excludedAllergenSet(request.ExcludedAllergens)
+
+	var sendErr error
+	s.Recipes.StreamMatchingRecipeNameSets(stream.Context(), availableProducts, s.ProductDensityMap, recipes.UniformRecipeScale(int(request.NumberOfServings)), int(request.MaxSuggestions), excludedAllergens, func(recipeNames []string) bool {
+		sendErr = stream.Send(s.recipeSuggestionSet(recipeNames))
+		return sendErr == nil
+	})
+	if sendErr != nil {
+		return fmt.Errorf("sending recipe suggestion set: %w", sendErr)
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+// Package httpapi exposes the foodtp-server functionality over JSON HTTP:
+// listing products, listing the units a product can be measured in, and
+// suggesting recipes that can be made from a pantry of available products.
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/rgeorgiev583/foodtp-server/recipes"
+	"github.com/rgeorgiev583/foodtp-server/units"
+)
+
+// Server holds the in-memory state every handler needs to serve a request.
+// It is safe for concurrent use: all of its fields are populated once at
+// startup and only read afterwards.
+type Server struct {
+	Products          []string
+	ProductUnitsMap   map[string]units.StringSet
+	Recipes           recipes.RecipeTable
+	RecipeSources     recipes.RecipeSourceMap
+	UnitConversion    *units.UnitConversionContext
+	UnitAlias         *units.UnitAliasContext
+	ProductAliasMap   units.BaseAliasMap
+	ProductDensityMap units.ProductDensityMap
+	AllergenTable     *units.AllergenTable
+
+	// HTTPOrigin, when non-empty, is written as the
+	// Access-Control-Allow-Origin response header on every response.
+	HTTPOrigin string
+}
+
+// apiError is a handlerFunc error that carries the HTTP status code it
+// should be reported with; any other error is reported as a 500.
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+func newAPIError(status int, format string, args ...interface{}) *apiError {
+	return &apiError{Status: status, Message: fmt.Sprintf(format, args...)}
+}
+
+type handlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+func (s *Server) wrap(h handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := h(w, r); err != nil {
+			var apiErr *apiError
+			if errors.As(err, &apiErr) {
+				http.Error(w, apiErr.Message, apiErr.Status)
+				return
+			}
+			log.Printf("internal error handling %s %s: %v", r.Method, r.URL.Path, err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+func (s *Server) handleProducts(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(s.Products)
+}
+
+func (s *Server) handleUnits(w http.ResponseWriter, r *http.Request) error {
+	var request recipes.ProductUnitsRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return newAPIError(http.StatusBadRequest, "malformed request body: %v", err)
+	}
+	defer r.Body.Close()
+
+	productUnits, ok := s.ProductUnitsMap[request.Product]
+	if !ok {
+		return newAPIError(http.StatusNotFound, "unknown product %q", request.Product)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(productUnits.Sorted())
+}
+
+func (s *Server) handleAllergens(w http.ResponseWriter, r *http.Request) error {
+	var allergens []string
+	if s.AllergenTable != nil {
+		allergens = s.AllergenTable.Allergens()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(allergens)
+}
+
+func (s *Server) handleShoppingList(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return newAPIError(http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+	}
+
+	var request recipes.ShoppingListRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return newAPIError(http.StatusBadRequest, "malformed request body: %v", err)
+	}
+	defer r.Body.Close()
+
+	for productName, product := range request.AvailableProducts {
+		product.Name = productName
+		product.ConvertUnit(s.UnitConversion, s.UnitAlias, s.ProductAliasMap)
+		if product.Name != productName {
+			request.AvailableProducts[product.Name] = product
+			delete(request.AvailableProducts, productName)
+		}
+	}
+
+	entries := make([]*recipes.ShoppingListEntry, 0, len(request.DesiredRecipes))
+	for _, recipeName := range request.DesiredRecipes {
+		recipe, ok := s.Recipes[recipeName]
+		if !ok {
+			entries = append(entries, &recipes.ShoppingListEntry{
+				Recipe: recipeName,
+				Error:  fmt.Sprintf("unknown recipe %q", recipeName),
+			})
+			continue
+		}
+
+		deficit, err := recipes.ComputeDeficit(recipe, request.AvailableProducts, request.NumberOfServings, s.ProductDensityMap)
+		if err != nil {
+			entries = append(entries, &recipes.ShoppingListEntry{Recipe: recipeName, Error: err.Error()})
+			continue
+		}
+
+		entries = append(entries, &recipes.ShoppingListEntry{Recipe: recipeName, Deficit: deficit})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(entries)
+}
+
+func (s *Server) handleRecipes(w http.ResponseWriter, r *http.Request) error {
+	var request recipes.RecipeSuggestionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return newAPIError(http.StatusBadRequest, "malformed request body: %v", err)
+	}
+	defer r.Body.Close()
+
+	for productName, product := range request.AvailableProducts {
+		product.Name = productName
+		product.ConvertUnit(s.UnitConversion, s.UnitAlias, s.ProductAliasMap)
+		if product.Name != productName {
+			request.AvailableProducts[product.Name] = product
+			delete(request.AvailableProducts, productName)
+		}
+	}
+
+	searchCtx := r.Context()
+	if request.TimeoutMS > 0 {
+		var cancel context.CancelFunc
+		searchCtx, cancel = context.WithTimeout(searchCtx, time.Duration(request.TimeoutMS)*time.Millisecond)
+		defer cancel()
+	}
+
+	excludedAllergens := make(units.StringSet, len(request.ExcludedAllergens))
+	for _, allergen := range request.ExcludedAllergens {
+		excludedAllergens[allergen] = struct{}{}
+	}
+
+	var scale recipes.RecipeScale
+	if request.LimitingIngredient != "" && request.LimitingQuantity != nil {
+		scale = recipes.LimitingIngredientRecipeScale(request.LimitingIngredient, request.LimitingQuantity, s.ProductDensityMap)
+	} else {
+		scale = recipes.UniformRecipeScale(request.NumberOfServings)
+	}
+
+	matchingRecipeNameSets := s.Recipes.GetMatchingRecipeNameSets(searchCtx, request.AvailableProducts, s.ProductDensityMap, scale, request.MaxSuggestions, excludedAllergens)
+
+	matchingRecipeSetResponseList := make([][]*recipes.RecipeSuggestionsResponse, 0, len(matchingRecipeNameSets))
+	for _, matchingRecipeNameSet := range matchingRecipeNameSets {
+		matchingRecipeSetResponse := make([]*recipes.RecipeSuggestionsResponse, 0, len(matchingRecipeNameSet))
+		for _, matchingRecipeName := range matchingRecipeNameSet {
+			matchingRecipeSource, ok := s.RecipeSources[matchingRecipeName]
+			if !ok {
+				return fmt.Errorf("recipe %q has no known source", matchingRecipeName)
+			}
+
+			recipe := s.Recipes[matchingRecipeName]
+			recipeScale, _ := scale(matchingRecipeName, recipe)
+
+			response := &recipes.RecipeSuggestionsResponse{
+				Name:      matchingRecipeName,
+				Source:    matchingRecipeSource,
+				Allergens: s.Recipes.Allergens(matchingRecipeName),
+				Scale:     recipeScale,
+			}
+			if request.LimitingIngredient != "" && request.LimitingQuantity != nil {
+				scaledIngredients := make([]*recipes.Product, 0, len(recipe))
+				for _, ingredient := range recipe {
+					scaledIngredients = append(scaledIngredients, &recipes.Product{
+						Name:        ingredient.Name,
+						Measurement: &units.Measurement{Quantity: ingredient.Quantity * recipeScale, Unit: ingredient.Measurement.Unit},
+						Allergens:   ingredient.Allergens,
+					})
+				}
+				response.ScaledIngredients = scaledIngredients
+			}
+
+			matchingRecipeSetResponse = append(matchingRecipeSetResponse, response)
+		}
+		matchingRecipeSetResponseList = append(matchingRecipeSetResponseList, matchingRecipeSetResponse)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(matchingRecipeSetResponseList)
+}
+
+// loggingMiddleware logs the method, path and handling time of every
+// request.
+func loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		log.Printf("%s %s %s", r.Method, r.URL.Path, time.Since(start))
+	})
+}
+
+// corsMiddleware writes the Access-Control-Allow-Origin header for every
+// response when origin is non-empty.
+func corsMiddleware(origin string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if origin == "" {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Routes returns the server's HTTP handler, with logging and CORS
+// middleware applied.
+func (s *Server) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/products", s.wrap(s.handleProducts))
+	mux.HandleFunc("/units", s.wrap(s.handleUnits))
+	mux.HandleFunc("/recipes", s.wrap(s.handleRecipes))
+	mux.HandleFunc("/allergens", s.wrap(s.handleAllergens))
+	mux.HandleFunc("/shopping-list", s.wrap(s.handleShoppingList))
+
+	return loggingMiddleware(corsMiddleware(s.HTTPOrigin)(mux))
+}
@@ -0,0 +1,622 @@
+// Package recipes models recipes and the products that go into them, and
+// implements the branch-and-bound search that matches a pantry of
+// available products against the recipes it can make.
+package recipes
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/rgeorgiev583/foodtp-server/units"
+)
+
+const toTasteUnitName = "to taste"
+
+type Product struct {
+	Name string
+	*units.Measurement
+	// Allergens is the sorted list of allergens this product is tagged
+	// with, if an allergen table was loaded; nil otherwise.
+	Allergens []string `json:"allergens,omitempty"`
+}
+
+// AssignAllergens sets p.Allergens from allergenTable, looked up by p's
+// current name. Call it after any alias resolution (e.g. ConvertUnit) so
+// the lookup uses the canonical product name.
+func (p *Product) AssignAllergens(allergenTable *units.AllergenTable) {
+	if allergenTable == nil {
+		return
+	}
+	p.Allergens = allergenTable.ProductAllergens(p.Name)
+}
+
+type ProductMap map[string]*Product
+type RecipeTable map[string]ProductMap
+type RecipeSourceMap map[string]string
+
+type ProductUnitsRequest struct {
+	Product string `json:"product"`
+}
+
+type RecipeSuggestionsRequest struct {
+	NumberOfServings  int        `json:"numberOfServings"`
+	AvailableProducts ProductMap `json:"availableProducts"`
+	// MaxSuggestions caps the number of matching recipe sets returned by the
+	// search; zero or negative means unlimited.
+	MaxSuggestions int `json:"maxSuggestions"`
+	// TimeoutMS bounds how long the search may run for, in milliseconds;
+	// zero or negative means no timeout.
+	TimeoutMS int `json:"timeoutMs"`
+	// ExcludedAllergens lists allergens that disqualify a recipe from being
+	// suggested if any of its ingredients is tagged with one.
+	ExcludedAllergens []string `json:"excludedAllergens"`
+	// LimitingIngredient and LimitingQuantity, when both set, scale every
+	// matching recipe so that it uses exactly LimitingQuantity of
+	// LimitingIngredient, instead of scaling every recipe by
+	// NumberOfServings; a recipe that doesn't contain LimitingIngredient at
+	// all is skipped rather than matched at some arbitrary scale.
+	LimitingIngredient string             `json:"limitingIngredient,omitempty"`
+	LimitingQuantity   *units.Measurement `json:"limitingQuantity,omitempty"`
+}
+
+type RecipeSuggestionsResponse struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	// Allergens is the union of the allergens of this recipe's
+	// ingredients.
+	Allergens []string `json:"allergens,omitempty"`
+	// Scale is the factor this recipe's ingredient quantities were
+	// multiplied by: NumberOfServings, or, when a LimitingIngredient was
+	// requested, the fractional factor that consumes exactly
+	// LimitingQuantity of it.
+	Scale float64 `json:"scale"`
+	// ScaledIngredients is recipe's ingredients with quantities multiplied
+	// by Scale, populated only when LimitingIngredient was requested.
+	ScaledIngredients []*Product `json:"scaledIngredients,omitempty"`
+}
+
+type ShoppingListRequest struct {
+	NumberOfServings  int        `json:"numberOfServings"`
+	AvailableProducts ProductMap `json:"availableProducts"`
+	DesiredRecipes    []string   `json:"desiredRecipes"`
+}
+
+// ShoppingListEntry reports, for one of a ShoppingListRequest's
+// DesiredRecipes, either the ingredients the pantry is short on (Deficit,
+// empty if none) or why the recipe couldn't be evaluated at all (Error).
+type ShoppingListEntry struct {
+	Recipe  string     `json:"recipe"`
+	Deficit ProductMap `json:"deficit,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// Allergens returns the sorted union of the allergens of recipeName's
+// ingredients, or nil if the recipe is unknown or none of its ingredients
+// are tagged with an allergen.
+func (t RecipeTable) Allergens(recipeName string) []string {
+	recipe, ok := t[recipeName]
+	if !ok {
+		return nil
+	}
+
+	seenAllergens := make(units.StringSet)
+	for _, ingredient := range recipe {
+		for _, allergen := range ingredient.Allergens {
+			seenAllergens[allergen] = struct{}{}
+		}
+	}
+	return seenAllergens.Sorted()
+}
+
+func (t RecipeTable) ImportFromCSVFile(filename string, products units.StringSet) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	bufferedReader := bufio.NewReader(file)
+	if _, _, err := bufferedReader.ReadLine(); err != nil {
+		return err
+	}
+
+	csvReader := csv.NewReader(bufferedReader)
+	csvReader.ReuseRecord = true
+
+	for {
+		ingredientRecord, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		recipeName := ingredientRecord[3]
+		recipe, ok := t[recipeName]
+		if !ok {
+			recipe = ProductMap{}
+			t[recipeName] = recipe
+		}
+		ingredientQuantityStr := ingredientRecord[1]
+		var ingredientQuantity float64
+		if ingredientQuantityStr != units.FieldNotApplicableStr {
+			ingredientQuantity, err = strconv.ParseFloat(ingredientQuantityStr, 64)
+			if err != nil {
+				return err
+			}
+		}
+
+		recipe[ingredientRecord[0]] = &Product{
+			Name: ingredientRecord[0],
+			Measurement: &units.Measurement{
+				Quantity: ingredientQuantity,
+				Unit:     ingredientRecord[2],
+			},
+		}
+		products[ingredientRecord[0]] = struct{}{}
+	}
+
+	return nil
+}
+
+func (m RecipeSourceMap) ImportFromCSVFile(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	bufferedReader := bufio.NewReader(file)
+	if _, _, err := bufferedReader.ReadLine(); err != nil {
+		return err
+	}
+
+	csvReader := csv.NewReader(bufferedReader)
+	csvReader.ReuseRecord = true
+
+	for {
+		recipeRecord, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		recipeName := recipeRecord[0]
+		recipeSource := recipeRecord[4]
+		m[recipeName] = recipeSource
+	}
+
+	return nil
+}
+
+// ConvertUnit rewrites p in place to the base unit and canonical name its
+// measurement and name resolve to under the given conversion/alias context.
+func (p *Product) ConvertUnit(unitConversionContext *units.UnitConversionContext, unitAliasContext *units.UnitAliasContext, productAliasMap units.BaseAliasMap) {
+	units.ConvertUnit(p.Measurement, &p.Name, unitConversionContext, unitAliasContext, productAliasMap)
+}
+
+// recipeBitmask is a fixed-width bitset over the recipe search order, used to
+// cheaply test whether one candidate recipe name set is a subset of another
+// without repeatedly walking both name slices.
+type recipeBitmask []uint64
+
+func newRecipeBitmask(numRecipes int) recipeBitmask {
+	return make(recipeBitmask, (numRecipes+63)/64)
+}
+
+func (b recipeBitmask) withBit(i int) recipeBitmask {
+	withBit := make(recipeBitmask, len(b))
+	copy(withBit, b)
+	withBit[i/64] |= 1 << uint(i%64)
+	return withBit
+}
+
+func (b recipeBitmask) isSubsetOf(other recipeBitmask) bool {
+	for i, word := range b {
+		if word&^other[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+type recipeNameCandidate struct {
+	mask  recipeBitmask
+	names []string
+}
+
+// recipeCoverageScore is a cheap upper-bound heuristic used to order the
+// branch-and-bound search: recipes whose ingredients are already mostly
+// present in the pantry are explored first, so promising (and eventually
+// maximal) recipe sets are discovered early and unpromising branches are
+// pruned sooner.
+func recipeCoverageScore(recipe ProductMap, availableProducts ProductMap) int {
+	score := 0
+	for ingredientName := range recipe {
+		if _, ok := availableProducts[ingredientName]; ok {
+			score++
+		}
+	}
+	return score
+}
+
+// recipeHasExcludedAllergen reports whether any of recipe's ingredients is
+// tagged with an allergen in excludedAllergens.
+func recipeHasExcludedAllergen(recipe ProductMap, excludedAllergens units.StringSet) bool {
+	if len(excludedAllergens) == 0 {
+		return false
+	}
+	for _, ingredient := range recipe {
+		for _, allergen := range ingredient.Allergens {
+			if _, ok := excludedAllergens[allergen]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// UnitMismatchError reports that a recipe ingredient's measurement unit
+// could not be converted to the unit the pantry has that product in,
+// because no density conversion is registered for it or because the two
+// units belong to fundamentally different unit families (e.g. neither is
+// the other's density-converted mass/volume counterpart).
+type UnitMismatchError struct {
+	Product    string
+	PantryUnit string
+	RecipeUnit string
+}
+
+func (e *UnitMismatchError) Error() string {
+	return fmt.Sprintf(`measurement units "%s" (from product list) and "%s" (from recipe) are incomparable for %q`, e.PantryUnit, e.RecipeUnit, e.Product)
+}
+
+// computeRecipeBalance scales recipe's ingredient quantities by servings
+// and, for each one, either subtracts it from pantry (recorded in
+// updatedPantry) or, if pantry doesn't have enough of it, records the
+// shortfall in deficit, expressed in the ingredient's own unit. Units are
+// converted via densities when an ingredient's unit differs from the
+// pantry's; if they cannot be converted at all, computeRecipeBalance fails
+// with a *UnitMismatchError rather than silently treating the ingredient as
+// missing. Ingredients measured "to taste" never contribute to the deficit.
+func computeRecipeBalance(recipe ProductMap, pantry ProductMap, scale float64, densities units.ProductDensityMap) (updatedPantry ProductMap, deficit ProductMap, err error) {
+	updatedPantry = make(ProductMap, len(pantry))
+	for productName, product := range pantry {
+		productCopy := *product
+		measurementCopy := *product.Measurement
+		productCopy.Measurement = &measurementCopy
+		updatedPantry[productName] = &productCopy
+	}
+	deficit = ProductMap{}
+
+	for _, ingredient := range recipe {
+		if ingredient.Measurement.Unit == toTasteUnitName {
+			continue
+		}
+
+		neededQuantity := ingredient.Quantity * scale
+
+		pantryProduct, ok := updatedPantry[ingredient.Name]
+		if !ok {
+			deficit[ingredient.Name] = &Product{
+				Name:        ingredient.Name,
+				Measurement: &units.Measurement{Quantity: neededQuantity, Unit: ingredient.Measurement.Unit},
+			}
+			continue
+		}
+
+		var density *units.Density
+		neededQuantityInPantryUnit := neededQuantity
+		if pantryProduct.Measurement.Unit != ingredient.Measurement.Unit {
+			var densityOk, areUnitsIncomparable bool
+			density, densityOk = densities[ingredient.Name]
+			if densityOk {
+				if ingredient.Measurement.Unit == density.VolumeUnit && pantryProduct.Measurement.Unit == density.MassUnit {
+					neededQuantityInPantryUnit *= density.Quantity
+				} else if ingredient.Measurement.Unit == density.MassUnit && pantryProduct.Measurement.Unit == density.VolumeUnit {
+					neededQuantityInPantryUnit /= density.Quantity
+				} else {
+					areUnitsIncomparable = true
+				}
+			}
+			if !densityOk || areUnitsIncomparable {
+				return nil, nil, &UnitMismatchError{
+					Product:    ingredient.Name,
+					PantryUnit: pantryProduct.Measurement.Unit,
+					RecipeUnit: ingredient.Measurement.Unit,
+				}
+			}
+		}
+
+		if neededQuantityInPantryUnit <= pantryProduct.Quantity {
+			pantryProduct.Quantity -= neededQuantityInPantryUnit
+			continue
+		}
+
+		shortfallInPantryUnit := neededQuantityInPantryUnit - pantryProduct.Quantity
+		pantryProduct.Quantity = 0
+
+		shortfall := shortfallInPantryUnit
+		if density != nil {
+			if ingredient.Measurement.Unit == density.VolumeUnit {
+				shortfall /= density.Quantity
+			} else {
+				shortfall *= density.Quantity
+			}
+		}
+		deficit[ingredient.Name] = &Product{
+			Name:        ingredient.Name,
+			Measurement: &units.Measurement{Quantity: shortfall, Unit: ingredient.Measurement.Unit},
+		}
+	}
+
+	return updatedPantry, deficit, nil
+}
+
+// ComputeDeficit reports, for recipe scaled to servings, which of its
+// ingredients pantry doesn't have enough of, with quantities expressed in
+// the recipe's declared unit. A non-nil, empty deficit means the recipe can
+// be made in full. It returns a *UnitMismatchError if an ingredient's unit
+// cannot be compared against the pantry's unit for that product at all,
+// rather than silently leaving it out of the deficit.
+func ComputeDeficit(recipe ProductMap, pantry ProductMap, servings int, densities units.ProductDensityMap) (deficit ProductMap, err error) {
+	_, deficit, err = computeRecipeBalance(recipe, pantry, float64(servings), densities)
+	return
+}
+
+// RecipeScale resolves the factor a recipe's ingredient quantities should be
+// multiplied by before matching it against a pantry. It returns ok == false
+// if recipe cannot be scaled at all (e.g. a requested limiting ingredient it
+// doesn't contain), in which case the recipe is skipped rather than matched.
+type RecipeScale func(recipeName string, recipe ProductMap) (scale float64, ok bool)
+
+// UniformRecipeScale returns a RecipeScale that scales every recipe by the
+// same factor, numberOfServings.
+func UniformRecipeScale(numberOfServings int) RecipeScale {
+	return func(recipeName string, recipe ProductMap) (float64, bool) {
+		return float64(numberOfServings), true
+	}
+}
+
+// LimitingIngredientRecipeScale returns a RecipeScale that scales a recipe
+// so that it uses exactly limitingQuantity of limitingIngredient, converting
+// units via densities when the recipe measures limitingIngredient in a unit
+// other than limitingQuantity's. A recipe that doesn't contain
+// limitingIngredient, or whose unit can't be converted against
+// limitingQuantity's, is not scalable.
+func LimitingIngredientRecipeScale(limitingIngredient string, limitingQuantity *units.Measurement, densities units.ProductDensityMap) RecipeScale {
+	return func(recipeName string, recipe ProductMap) (float64, bool) {
+		ingredient, ok := recipe[limitingIngredient]
+		if !ok || ingredient.Quantity == 0 {
+			return 0, false
+		}
+
+		recipeQuantity := ingredient.Quantity
+		if ingredient.Measurement.Unit != limitingQuantity.Unit {
+			density, ok := densities[limitingIngredient]
+			if !ok {
+				return 0, false
+			}
+			if ingredient.Measurement.Unit == density.VolumeUnit && limitingQuantity.Unit == density.MassUnit {
+				recipeQuantity *= density.Quantity
+			} else if ingredient.Measurement.Unit == density.MassUnit && limitingQuantity.Unit == density.VolumeUnit {
+				recipeQuantity /= density.Quantity
+			} else {
+				return 0, false
+			}
+		}
+
+		return limitingQuantity.Quantity / recipeQuantity, true
+	}
+}
+
+// deductRecipe returns a copy of remainingProducts with recipe's
+// scaled ingredient quantities subtracted from it, and false if the recipe
+// cannot be made from remainingProducts at all, either because an
+// ingredient is missing, there isn't enough of it, or its measurement unit
+// cannot be converted (via productDensityMap) to the unit the pantry has it
+// in. Ingredients measured "to taste" never block a match.
+func deductRecipe(recipe ProductMap, remainingProducts ProductMap, productDensityMap units.ProductDensityMap, scale float64) (ProductMap, bool) {
+	updatedProducts, deficit, err := computeRecipeBalance(recipe, remainingProducts, scale, productDensityMap)
+	if err != nil {
+		log.Print(err)
+		return nil, false
+	}
+	if len(deficit) > 0 {
+		return nil, false
+	}
+	return updatedProducts, true
+}
+
+// recipeFeasible reports whether recipe can be made from remainingProducts
+// at scale, without allocating or returning the resulting pantry. Unlike
+// deductRecipe, it doesn't log *UnitMismatchError, since it is called
+// speculatively (possibly many times per search node) to bound what a
+// branch could still reach, not to actually commit to a choice.
+func recipeFeasible(recipe ProductMap, remainingProducts ProductMap, productDensityMap units.ProductDensityMap, scale float64) bool {
+	_, deficit, err := computeRecipeBalance(recipe, remainingProducts, scale, productDensityMap)
+	return err == nil && len(deficit) == 0
+}
+
+// reachableMask extends chosenMask with every recipe at or after index in
+// order that is individually feasible against remainingProducts. Because
+// deductRecipe never returns a pantry with more of anything than it was
+// given, a recipe that is infeasible against remainingProducts can never
+// become feasible deeper in the same branch — so the result is a valid
+// upper bound on the recipe set any candidate found below this search node
+// could contain, even though not every recipe in it may be jointly
+// reachable at once.
+func reachableMask(order []string, t RecipeTable, remainingProducts ProductMap, productDensityMap units.ProductDensityMap, scale RecipeScale, index int, chosenMask recipeBitmask) recipeBitmask {
+	mask := chosenMask
+	for i := index; i < len(order); i++ {
+		recipeName := order[i]
+		recipeScale, ok := scale(recipeName, t[recipeName])
+		if !ok {
+			continue
+		}
+		if !recipeFeasible(t[recipeName], remainingProducts, productDensityMap, recipeScale) {
+			continue
+		}
+		mask = mask.withBit(i)
+	}
+	return mask
+}
+
+// recipeSearchOrder returns the recipe names from t that don't contain an
+// excluded allergen, sorted by recipeCoverageScore descending.
+func recipeSearchOrder(t RecipeTable, availableProducts ProductMap, excludedAllergens units.StringSet) []string {
+	order := make([]string, 0, len(t))
+	for recipeName, recipe := range t {
+		if recipeHasExcludedAllergen(recipe, excludedAllergens) {
+			continue
+		}
+		order = append(order, recipeName)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return recipeCoverageScore(t[order[i]], availableProducts) > recipeCoverageScore(t[order[j]], availableProducts)
+	})
+	return order
+}
+
+// searchMaximalRecipeNameSets runs the depth-first branch-and-bound search
+// over order, calling onCandidate with every maximal recipe name set it
+// finds (see GetMatchingRecipeNameSets for what "maximal" means here). A
+// search node is pruned as soon as reachableMask shows it can't reach
+// anything beyond a maximal set already found, so recipes that are all
+// jointly compatible (the common case) collapse to roughly one set instead
+// of enumerating every feasible subsequence of them. It stops early if
+// onCandidate returns false, if maxSuggestions candidates have already been
+// reported (when maxSuggestions > 0), or if ctx is done.
+func searchMaximalRecipeNameSets(ctx context.Context, order []string, t RecipeTable, availableProducts ProductMap, productDensityMap units.ProductDensityMap, scale RecipeScale, maxSuggestions int, onCandidate func(names []string, mask recipeBitmask) bool) {
+	reported := 0
+	stopped := false
+	var foundMasks []recipeBitmask
+
+	var search func(index int, chosenNames []string, chosenMask recipeBitmask, remainingProducts ProductMap)
+	search = func(index int, chosenNames []string, chosenMask recipeBitmask, remainingProducts ProductMap) {
+		if stopped {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			stopped = true
+			return
+		default:
+		}
+		if maxSuggestions > 0 && reported >= maxSuggestions {
+			stopped = true
+			return
+		}
+
+		bound := reachableMask(order, t, remainingProducts, productDensityMap, scale, index, chosenMask)
+		for _, found := range foundMasks {
+			if bound.isSubsetOf(found) {
+				return
+			}
+		}
+
+		extended := false
+		for i := index; i < len(order); i++ {
+			recipeName := order[i]
+			recipeScale, ok := scale(recipeName, t[recipeName])
+			if !ok {
+				continue
+			}
+			newRemainingProducts, ok := deductRecipe(t[recipeName], remainingProducts, productDensityMap, recipeScale)
+			if !ok {
+				continue
+			}
+
+			extended = true
+			newChosenNames := make([]string, len(chosenNames)+1)
+			copy(newChosenNames, chosenNames)
+			newChosenNames[len(chosenNames)] = recipeName
+			search(i+1, newChosenNames, chosenMask.withBit(i), newRemainingProducts)
+
+			if stopped {
+				return
+			}
+		}
+
+		if !extended && len(chosenNames) > 0 {
+			reported++
+			foundMasks = append(foundMasks, chosenMask)
+			if !onCandidate(chosenNames, chosenMask) {
+				stopped = true
+			}
+		}
+	}
+
+	initialRemainingProducts := make(ProductMap, len(availableProducts))
+	for productName, product := range availableProducts {
+		productCopy := *product
+		measurementCopy := *product.Measurement
+		productCopy.Measurement = &measurementCopy
+		initialRemainingProducts[productName] = &productCopy
+	}
+	search(0, nil, newRecipeBitmask(len(order)), initialRemainingProducts)
+}
+
+// GetMatchingRecipeNameSets performs a depth-first branch-and-bound search
+// over the recipe table, rather than materializing and scanning its power
+// set, which would use O(2^N) memory and time. Recipes are visited in order
+// of recipeCoverageScore, and a branch is only explored if the recipe it
+// adds can actually be made from the pantry remaining after every
+// previously chosen recipe in that branch. A candidate set is recorded only
+// once no further recipe can be added to it, which guarantees every
+// recorded set is maximal; a final bitmask-based pass discards any
+// candidate that nonetheless turns out to be a subset of another one found.
+//
+// maxSuggestions caps the number of maximal sets collected (zero or
+// negative means unlimited), and ctx bounds how long the search may run;
+// both are best-effort and may yield a result that misses some maximal
+// sets that a longer search would have found.
+func (t RecipeTable) GetMatchingRecipeNameSets(ctx context.Context, availableProducts ProductMap, productDensityMap units.ProductDensityMap, scale RecipeScale, maxSuggestions int, excludedAllergens units.StringSet) (matchingRecipeNameSets [][]string) {
+	order := recipeSearchOrder(t, availableProducts, excludedAllergens)
+
+	var candidates []recipeNameCandidate
+	searchMaximalRecipeNameSets(ctx, order, t, availableProducts, productDensityMap, scale, maxSuggestions, func(names []string, mask recipeBitmask) bool {
+		candidates = append(candidates, recipeNameCandidate{mask: mask, names: names})
+		return true
+	})
+
+	matchingRecipeNameSets = make([][]string, 0, len(candidates))
+	for i, candidate := range candidates {
+		isSubset := false
+		for j, other := range candidates {
+			if i != j && len(candidate.names) < len(other.names) && candidate.mask.isSubsetOf(other.mask) {
+				isSubset = true
+				break
+			}
+		}
+		if !isSubset {
+			matchingRecipeNameSets = append(matchingRecipeNameSets, candidate.names)
+		}
+	}
+
+	return
+}
+
+// StreamMatchingRecipeNameSets runs the same branch-and-bound search as
+// GetMatchingRecipeNameSets, but calls onMatch with each maximal recipe
+// name set as soon as it is discovered, instead of collecting them all
+// before returning. Because sets are reported as found, a set reported
+// early may later turn out to be a subset of one found afterwards; callers
+// that need only maximal sets should use GetMatchingRecipeNameSets instead.
+// Streaming stops as soon as onMatch returns false.
+func (t RecipeTable) StreamMatchingRecipeNameSets(ctx context.Context, availableProducts ProductMap, productDensityMap units.ProductDensityMap, scale RecipeScale, maxSuggestions int, excludedAllergens units.StringSet, onMatch func(names []string) bool) {
+	order := recipeSearchOrder(t, availableProducts, excludedAllergens)
+	searchMaximalRecipeNameSets(ctx, order, t, availableProducts, productDensityMap, scale, maxSuggestions, func(names []string, mask recipeBitmask) bool {
+		return onMatch(names)
+	})
+}
@@ -0,0 +1,199 @@
+package recipes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rgeorgiev583/foodtp-server/units"
+)
+
+func writeTestFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.csv")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("writing test fixture %s: %v", path, err)
+	}
+	return path
+}
+
+func TestRecipeTable_ImportFromCSVFile(t *testing.T) {
+	path := writeTestFile(t, "ingredient,quantity,unit,recipe\neggs,2,count,omelette\nmilk,200,ml,pancakes\n")
+
+	recipeTable := RecipeTable{}
+	products := units.StringSet{}
+
+	if err := recipeTable.ImportFromCSVFile(path, products); err != nil {
+		t.Fatalf("ImportFromCSVFile(...) returned error: %v", err)
+	}
+
+	eggs, ok := recipeTable["omelette"]["eggs"]
+	if !ok || *eggs.Measurement != (units.Measurement{Quantity: 2, Unit: "count"}) {
+		t.Errorf("recipeTable[\"omelette\"][\"eggs\"] = %+v, %v, want {2 count}, true", eggs, ok)
+	}
+	milk, ok := recipeTable["pancakes"]["milk"]
+	if !ok || *milk.Measurement != (units.Measurement{Quantity: 200, Unit: "ml"}) {
+		t.Errorf("recipeTable[\"pancakes\"][\"milk\"] = %+v, %v, want {200 ml}, true", milk, ok)
+	}
+
+	if _, ok := products["eggs"]; !ok {
+		t.Errorf("products = %v, want it to contain \"eggs\"", products)
+	}
+	if _, ok := products["milk"]; !ok {
+		t.Errorf("products = %v, want it to contain \"milk\"", products)
+	}
+}
+
+func TestRecipeTable_ImportFromCSVFile_MissingFile(t *testing.T) {
+	recipeTable := RecipeTable{}
+	if err := recipeTable.ImportFromCSVFile(filepath.Join(t.TempDir(), "does-not-exist.csv"), units.StringSet{}); err == nil {
+		t.Error("ImportFromCSVFile(...) = nil, want an error for a nonexistent file")
+	}
+}
+
+func TestRecipeSourceMap_ImportFromCSVFile(t *testing.T) {
+	path := writeTestFile(t, "recipe,col1,col2,col3,source\nomelette,,,,https://example.com/omelette\n")
+
+	recipeSources := RecipeSourceMap{}
+	if err := recipeSources.ImportFromCSVFile(path); err != nil {
+		t.Fatalf("ImportFromCSVFile(...) returned error: %v", err)
+	}
+
+	if got, want := recipeSources["omelette"], "https://example.com/omelette"; got != want {
+		t.Errorf("recipeSources[\"omelette\"] = %q, want %q", got, want)
+	}
+}
+
+func TestRecipeSourceMap_ImportFromCSVFile_MissingFile(t *testing.T) {
+	recipeSources := RecipeSourceMap{}
+	if err := recipeSources.ImportFromCSVFile(filepath.Join(t.TempDir(), "does-not-exist.csv")); err == nil {
+		t.Error("ImportFromCSVFile(...) = nil, want an error for a nonexistent file")
+	}
+}
+
+// buildBenchmarkRecipeTable constructs numRecipes recipes, each needing a
+// handful of ingredients drawn from a shared product pool, so that many
+// recipes are at least partially coverable by the benchmark pantry.
+func buildBenchmarkRecipeTable(numRecipes int) (RecipeTable, ProductMap) {
+	const ingredientsPerRecipe = 4
+	const productPoolSize = 20
+
+	availableProducts := make(ProductMap, productPoolSize)
+	for i := 0; i < productPoolSize; i++ {
+		productName := fmt.Sprintf("product-%d", i)
+		availableProducts[productName] = &Product{
+			Name:        productName,
+			Measurement: &units.Measurement{Quantity: 100, Unit: "g"},
+		}
+	}
+
+	recipeTable := make(RecipeTable, numRecipes)
+	for i := 0; i < numRecipes; i++ {
+		recipe := make(ProductMap, ingredientsPerRecipe)
+		for j := 0; j < ingredientsPerRecipe; j++ {
+			productName := fmt.Sprintf("product-%d", (i+j)%productPoolSize)
+			recipe[productName] = &Product{
+				Name:        productName,
+				Measurement: &units.Measurement{Quantity: 1, Unit: "g"},
+			}
+		}
+		recipeTable[fmt.Sprintf("recipe-%d", i)] = recipe
+	}
+
+	return recipeTable, availableProducts
+}
+
+// TestComputeDeficit_DoesNotMutatePantry checks that calling ComputeDeficit
+// for one recipe doesn't deplete the pantry seen by a later call for a
+// different recipe that draws on the same product — as handleShoppingList
+// does once per DesiredRecipes entry, reusing the same AvailableProducts.
+func TestComputeDeficit_DoesNotMutatePantry(t *testing.T) {
+	pantry := ProductMap{
+		"eggs": &Product{Name: "eggs", Measurement: &units.Measurement{Quantity: 10, Unit: "count"}},
+	}
+	omelette := ProductMap{
+		"eggs": &Product{Name: "eggs", Measurement: &units.Measurement{Quantity: 4, Unit: "count"}},
+	}
+	frittata := ProductMap{
+		"eggs": &Product{Name: "eggs", Measurement: &units.Measurement{Quantity: 6, Unit: "count"}},
+	}
+
+	omeletteDeficit, err := ComputeDeficit(omelette, pantry, 1, units.ProductDensityMap{})
+	if err != nil {
+		t.Fatalf("ComputeDeficit(omelette, ...) returned error: %v", err)
+	}
+	if len(omeletteDeficit) != 0 {
+		t.Errorf("ComputeDeficit(omelette, ...) = %v, want no deficit", omeletteDeficit)
+	}
+	if pantry["eggs"].Quantity != 10 {
+		t.Fatalf("pantry[\"eggs\"].Quantity = %v after omelette, want unchanged at 10", pantry["eggs"].Quantity)
+	}
+
+	frittataDeficit, err := ComputeDeficit(frittata, pantry, 1, units.ProductDensityMap{})
+	if err != nil {
+		t.Fatalf("ComputeDeficit(frittata, ...) returned error: %v", err)
+	}
+	if len(frittataDeficit) != 0 {
+		t.Errorf("ComputeDeficit(frittata, ...) = %v, want no deficit (pantry has 10 eggs, frittata needs only 6)", frittataDeficit)
+	}
+}
+
+// TestGetMatchingRecipeNameSets_CompetingRecipesBothReported checks that two
+// recipes that each individually need more of a shared ingredient than is
+// left over once the other has been made (so they can never appear together
+// in one set) are still each reported in their own maximal set, rather than
+// one recipe's deduction leaking into the pantry considered for the other.
+func TestGetMatchingRecipeNameSets_CompetingRecipesBothReported(t *testing.T) {
+	availableProducts := ProductMap{
+		"eggs": &Product{Name: "eggs", Measurement: &units.Measurement{Quantity: 3, Unit: "count"}},
+	}
+	recipeTable := RecipeTable{
+		"omelette": ProductMap{
+			"eggs": &Product{Name: "eggs", Measurement: &units.Measurement{Quantity: 2, Unit: "count"}},
+		},
+		"scrambled-eggs": ProductMap{
+			"eggs": &Product{Name: "eggs", Measurement: &units.Measurement{Quantity: 2, Unit: "count"}},
+		},
+	}
+
+	matchingRecipeNameSets := recipeTable.GetMatchingRecipeNameSets(context.Background(), availableProducts, units.ProductDensityMap{}, UniformRecipeScale(1), 0, nil)
+
+	containsRecipe := func(recipeName string) bool {
+		for _, set := range matchingRecipeNameSets {
+			for _, name := range set {
+				if name == recipeName {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if !containsRecipe("omelette") || !containsRecipe("scrambled-eggs") {
+		t.Errorf("GetMatchingRecipeNameSets(...) = %v, want both omelette and scrambled-eggs reported (each in their own set, since together they need more eggs than available)", matchingRecipeNameSets)
+	}
+	if availableProducts["eggs"].Quantity != 3 {
+		t.Errorf("availableProducts[\"eggs\"].Quantity = %v after search, want unchanged at 3", availableProducts["eggs"].Quantity)
+	}
+}
+
+func BenchmarkGetMatchingRecipeNameSets_100Recipes(b *testing.B) {
+	recipeTable, availableProducts := buildBenchmarkRecipeTable(100)
+	productDensityMap := units.ProductDensityMap{}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		recipeTable.GetMatchingRecipeNameSets(context.Background(), availableProducts, productDensityMap, UniformRecipeScale(1), 0, nil)
+	}
+}
+
+func BenchmarkGetMatchingRecipeNameSets_200RecipesCapped(b *testing.B) {
+	recipeTable, availableProducts := buildBenchmarkRecipeTable(200)
+	productDensityMap := units.ProductDensityMap{}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		recipeTable.GetMatchingRecipeNameSets(context.Background(), availableProducts, productDensityMap, UniformRecipeScale(1), 10, nil)
+	}
+}
@@ -0,0 +1,68 @@
+package units
+
+import "gopkg.in/ini.v1"
+
+// AllergenTable records which products are tagged with which allergens
+// (e.g. "gluten", "nuts", "dairy"), loaded from an INI file whose sections
+// are allergen names and whose keys are the names of products tagged with
+// that allergen.
+type AllergenTable struct {
+	byAllergen map[string]StringSet
+	byProduct  map[string]StringSet
+}
+
+func NewAllergenTable() *AllergenTable {
+	return &AllergenTable{
+		byAllergen: map[string]StringSet{},
+		byProduct:  map[string]StringSet{},
+	}
+}
+
+func (t *AllergenTable) ImportFromINIFile(filename string) error {
+	file, err := ini.Load(filename)
+	if err != nil {
+		return err
+	}
+
+	for _, section := range file.Sections() {
+		allergen := section.Name()
+		if allergen == IniDefaultSectionName {
+			continue
+		}
+
+		for _, key := range section.Keys() {
+			product := key.Name()
+
+			productSet, ok := t.byAllergen[allergen]
+			if !ok {
+				productSet = StringSet{}
+				t.byAllergen[allergen] = productSet
+			}
+			productSet[product] = struct{}{}
+
+			allergenSet, ok := t.byProduct[product]
+			if !ok {
+				allergenSet = StringSet{}
+				t.byProduct[product] = allergenSet
+			}
+			allergenSet[allergen] = struct{}{}
+		}
+	}
+
+	return nil
+}
+
+// Allergens returns the sorted list of allergen names known to the table.
+func (t *AllergenTable) Allergens() []string {
+	names := make(StringSet, len(t.byAllergen))
+	for allergen := range t.byAllergen {
+		names[allergen] = struct{}{}
+	}
+	return names.Sorted()
+}
+
+// ProductAllergens returns the sorted list of allergens the given product
+// is tagged with.
+func (t *AllergenTable) ProductAllergens(product string) []string {
+	return t.byProduct[product].Sorted()
+}
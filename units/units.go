@@ -0,0 +1,314 @@
+// Package units holds the unit-conversion and unit-aliasing machinery
+// shared by the recipes and httpapi packages: parsing conversion tables and
+// alias tables out of CSV/INI files, and converting a measurement between
+// culinary units (e.g. "cup" to "g") via per-product density.
+package units
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"gopkg.in/ini.v1"
+)
+
+const IniDefaultSectionName = "DEFAULT"
+const FieldNotApplicableStr = "-"
+
+var unitDescriptionPattern = regexp.MustCompile(`(.+?)\s*\((\d+)\s*(.+)\)`)
+
+type StringSet map[string]struct{}
+
+func (s StringSet) Sorted() (sorted []string) {
+	sorted = make([]string, 0, len(s))
+	for element := range s {
+		sorted = append(sorted, element)
+	}
+	sort.Strings(sorted)
+	return
+}
+
+type Measurement struct {
+	Quantity float64 `json:"quantity"`
+	Unit     string  `json:"unit"`
+}
+
+type BaseUnitConversionMap map[string]*Measurement
+type UnitConversionTable map[string]BaseUnitConversionMap
+type BaseAliasMap map[string]string
+type AliasTable map[string]BaseAliasMap
+
+type UnitConversionContext struct {
+	UnitConversionTable
+	BaseUnitConversionMap
+}
+
+type UnitAliasContext struct {
+	AliasTable
+	BaseAliasMap
+}
+
+type Density struct {
+	Quantity   float64
+	MassUnit   string
+	VolumeUnit string
+}
+type ProductDensityMap map[string]*Density
+
+func NewUnitConversionContext() *UnitConversionContext {
+	return &UnitConversionContext{
+		UnitConversionTable{},
+		BaseUnitConversionMap{},
+	}
+}
+
+func NewUnitAliasContext() *UnitAliasContext {
+	return &UnitAliasContext{
+		AliasTable{},
+		BaseAliasMap{},
+	}
+}
+
+func (ctx *UnitConversionContext) ImportFromCSVFile(filename string, productDensityMap ProductDensityMap, productUnitsMap map[string]StringSet) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	csvReader := csv.NewReader(file)
+	csvReader.ReuseRecord = true
+
+	unitRecord, err := csvReader.Read()
+	if err != nil {
+		return err
+	}
+
+	unitDescriptions := unitRecord[1:]
+	unitCount := len(unitDescriptions)
+	units := make([]string, 0, unitCount)
+
+	for _, unitDescription := range unitDescriptions {
+		unitDescriptionMatch := unitDescriptionPattern.FindStringSubmatch(unitDescription)
+		if len(unitDescriptionMatch) != 4 {
+			return fmt.Errorf("invalid format of culinary unit description: %q", unitDescription)
+		}
+
+		unit := unitDescriptionMatch[1]
+		units = append(units, unit)
+
+		unitQuantityStr := unitDescriptionMatch[2]
+		var baseUnitQuantity float64
+		if unitQuantityStr != FieldNotApplicableStr {
+			baseUnitQuantity, err = strconv.ParseFloat(unitQuantityStr, 64)
+			if err != nil {
+				return err
+			}
+		}
+		ctx.BaseUnitConversionMap[unit] = &Measurement{
+			Quantity: baseUnitQuantity,
+			Unit:     unitDescriptionMatch[3],
+		}
+	}
+
+	for {
+		productRecord, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		product := productRecord[0]
+
+		productUnitSet, ok := productUnitsMap[product]
+		if !ok {
+			productUnitSet = make(StringSet)
+			productUnitsMap[product] = productUnitSet
+		}
+
+		productDensity := &Density{}
+		productDensityMeasurementCount := 0
+
+		for i, productDensityMeasurementStr := range productRecord[1:] {
+			if productDensityMeasurementStr == FieldNotApplicableStr {
+				continue
+			}
+
+			productDensityMeasurement := &Measurement{}
+			_, err = fmt.Sscanln(productDensityMeasurementStr, &productDensityMeasurement.Quantity, &productDensityMeasurement.Unit)
+			if err != nil {
+				return err
+			}
+
+			unit := units[i]
+			unitDefinition, ok := ctx.UnitConversionTable[unit]
+			if !ok {
+				unitDefinition = make(BaseUnitConversionMap, unitCount)
+				ctx.UnitConversionTable[unit] = unitDefinition
+			}
+			unitDefinition[product] = productDensityMeasurement
+
+			productUnitSet[unit] = struct{}{}
+			productUnitSet[productDensityMeasurement.Unit] = struct{}{}
+
+			if productDensity.MassUnit == "" {
+				productDensity.MassUnit = productDensityMeasurement.Unit
+			}
+			if productDensity.VolumeUnit == "" {
+				productDensity.VolumeUnit = unit
+			}
+
+			if productDensity.MassUnit == productDensityMeasurement.Unit && productDensity.VolumeUnit == unit {
+				unitBaseDefinition, ok := ctx.BaseUnitConversionMap[unit]
+				if ok {
+					productDensity.Quantity += productDensityMeasurement.Quantity / unitBaseDefinition.Quantity
+					productDensityMeasurementCount++
+				}
+			}
+		}
+
+		productDensity.Quantity /= float64(productDensityMeasurementCount)
+		productDensityMap[product] = productDensity
+	}
+
+	return nil
+}
+
+func getMeasurement(str string) (*Measurement, error) {
+	m := &Measurement{}
+	_, err := fmt.Sscanln(str, &m.Quantity, &m.Unit)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (ctx *UnitConversionContext) ImportFromINIFile(filename string, productUnitsMap map[string]StringSet) error {
+	file, err := ini.Load(filename)
+	if err != nil {
+		return err
+	}
+
+	baseUnitDefinitions, err := file.GetSection(IniDefaultSectionName)
+	if err != nil {
+		return err
+	}
+	for _, baseUnitDefinition := range baseUnitDefinitions.Keys() {
+		measurement, err := getMeasurement(baseUnitDefinition.Value())
+		if err != nil {
+			return err
+		}
+		ctx.BaseUnitConversionMap[baseUnitDefinition.Name()] = measurement
+	}
+
+	unitSections := file.Sections()
+	for _, unitSection := range unitSections {
+		unit := unitSection.Name()
+		keys := unitSection.Keys()
+		unitDefinition := make(BaseUnitConversionMap, len(keys))
+
+		for _, key := range keys {
+			product := key.Name()
+			measurement, err := getMeasurement(key.Value())
+			if err != nil {
+				return err
+			}
+			unitDefinition[product] = measurement
+
+			unitSet, ok := productUnitsMap[product]
+			if !ok {
+				unitSet = make(StringSet, len(unitSections))
+				productUnitsMap[product] = unitSet
+			}
+			unitSet[unit] = struct{}{}
+			unitSet[measurement.Unit] = struct{}{}
+		}
+
+		ctx.UnitConversionTable[unit] = unitDefinition
+	}
+
+	return nil
+}
+
+func (ctx *UnitAliasContext) ImportFromINIFile(filename string) error {
+	file, err := ini.Load(filename)
+	if err != nil {
+		return err
+	}
+
+	baseAliasDefinitions, err := file.GetSection(IniDefaultSectionName)
+	if err != nil {
+		return err
+	}
+	for _, baseAliasDefinition := range baseAliasDefinitions.Keys() {
+		ctx.BaseAliasMap[baseAliasDefinition.Name()] = baseAliasDefinition.Value()
+	}
+
+	for _, unitSection := range file.Sections() {
+		aliasDefinitions := unitSection.Keys()
+		aliasMap := make(BaseAliasMap, len(aliasDefinitions))
+
+		for _, aliasDefinition := range aliasDefinitions {
+			aliasMap[aliasDefinition.Name()] = aliasDefinition.Value()
+		}
+
+		ctx.AliasTable[unitSection.Name()] = aliasMap
+	}
+
+	return nil
+}
+
+func (m BaseAliasMap) ImportFromINIFile(filename string) error {
+	file, err := ini.Load(filename)
+	if err != nil {
+		return err
+	}
+
+	section, err := file.GetSection(IniDefaultSectionName)
+	if err != nil {
+		return err
+	}
+	for _, aliasDefinition := range section.Keys() {
+		m[aliasDefinition.Name()] = aliasDefinition.Value()
+	}
+
+	return nil
+}
+
+// ConvertUnit rewrites unit (and, via productUnitMeasurement, quantity) of
+// measurement for the given product name to the base unit it resolves to
+// under ctx, after first resolving unit and product aliases. It reports
+// whether a conversion was applied.
+func ConvertUnit(measurement *Measurement, productName *string, unitConversionContext *UnitConversionContext, unitAliasContext *UnitAliasContext, productAliasMap BaseAliasMap) {
+	unitAliasDefinition, ok := unitAliasContext.AliasTable[measurement.Unit]
+	if ok {
+		unitAlias, ok := unitAliasDefinition[*productName]
+		if !ok {
+			unitAlias, ok = unitAliasContext.BaseAliasMap[measurement.Unit]
+		}
+		if ok {
+			measurement.Unit = unitAlias
+		}
+	}
+	productAlias, ok := productAliasMap[*productName]
+	if ok {
+		*productName = productAlias
+	}
+	var productUnitMeasurement *Measurement
+	productUnitDefinition, ok := unitConversionContext.UnitConversionTable[measurement.Unit]
+	if ok {
+		productUnitMeasurement, ok = productUnitDefinition[*productName]
+	} else {
+		productUnitMeasurement, ok = unitConversionContext.BaseUnitConversionMap[measurement.Unit]
+	}
+	if productUnitMeasurement != nil {
+		measurement.Unit = productUnitMeasurement.Unit
+		measurement.Quantity *= productUnitMeasurement.Quantity
+	}
+}
@@ -0,0 +1,85 @@
+package units
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnitConversionContext_ImportFromCSVFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "units.csv")
+	writeFile(t, path, "product,cup (240 g)\nflour,120 g\n")
+
+	ctx := NewUnitConversionContext()
+	productDensityMap := ProductDensityMap{}
+	productUnitsMap := map[string]StringSet{}
+
+	if err := ctx.ImportFromCSVFile(path, productDensityMap, productUnitsMap); err != nil {
+		t.Fatalf("ImportFromCSVFile(...) returned error: %v", err)
+	}
+
+	baseUnit, ok := ctx.BaseUnitConversionMap["cup"]
+	if !ok || *baseUnit != (Measurement{Quantity: 240, Unit: "g"}) {
+		t.Errorf("BaseUnitConversionMap[\"cup\"] = %+v, %v, want {240 g}, true", baseUnit, ok)
+	}
+
+	flourInCups, ok := ctx.UnitConversionTable["cup"]["flour"]
+	if !ok || *flourInCups != (Measurement{Quantity: 120, Unit: "g"}) {
+		t.Errorf("UnitConversionTable[\"cup\"][\"flour\"] = %+v, %v, want {120 g}, true", flourInCups, ok)
+	}
+
+	if _, ok := productUnitsMap["flour"]["cup"]; !ok {
+		t.Errorf("productUnitsMap[\"flour\"] = %v, want it to contain \"cup\"", productUnitsMap["flour"])
+	}
+	if _, ok := productUnitsMap["flour"]["g"]; !ok {
+		t.Errorf("productUnitsMap[\"flour\"] = %v, want it to contain \"g\"", productUnitsMap["flour"])
+	}
+
+	density, ok := productDensityMap["flour"]
+	if !ok || density.Quantity != 0.5 || density.MassUnit != "g" || density.VolumeUnit != "cup" {
+		t.Errorf("productDensityMap[\"flour\"] = %+v, %v, want {0.5 g cup}, true", density, ok)
+	}
+}
+
+func TestUnitConversionContext_ImportFromINIFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "units.ini")
+	writeFile(t, path, "[DEFAULT]\ncup = 240 g\n\n[cup]\nflour = 120 g\n")
+
+	ctx := NewUnitConversionContext()
+	productUnitsMap := map[string]StringSet{}
+
+	if err := ctx.ImportFromINIFile(path, productUnitsMap); err != nil {
+		t.Fatalf("ImportFromINIFile(...) returned error: %v", err)
+	}
+
+	baseUnit, ok := ctx.BaseUnitConversionMap["cup"]
+	if !ok || *baseUnit != (Measurement{Quantity: 240, Unit: "g"}) {
+		t.Errorf("BaseUnitConversionMap[\"cup\"] = %+v, %v, want {240 g}, true", baseUnit, ok)
+	}
+
+	flourInCups, ok := ctx.UnitConversionTable["cup"]["flour"]
+	if !ok || *flourInCups != (Measurement{Quantity: 120, Unit: "g"}) {
+		t.Errorf("UnitConversionTable[\"cup\"][\"flour\"] = %+v, %v, want {120 g}, true", flourInCups, ok)
+	}
+
+	if _, ok := productUnitsMap["flour"]["cup"]; !ok {
+		t.Errorf("productUnitsMap[\"flour\"] = %v, want it to contain \"cup\"", productUnitsMap["flour"])
+	}
+	if _, ok := productUnitsMap["flour"]["g"]; !ok {
+		t.Errorf("productUnitsMap[\"flour\"] = %v, want it to contain \"g\"", productUnitsMap["flour"])
+	}
+}
+
+func TestUnitConversionContext_ImportFromCSVFile_MissingFile(t *testing.T) {
+	ctx := NewUnitConversionContext()
+	if err := ctx.ImportFromCSVFile(filepath.Join(t.TempDir(), "does-not-exist.csv"), ProductDensityMap{}, map[string]StringSet{}); err == nil {
+		t.Error("ImportFromCSVFile(...) = nil, want an error for a nonexistent file")
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("writing test fixture %s: %v", path, err)
+	}
+}